@@ -0,0 +1,404 @@
+// Package bot implements Coffeebot's Slack-facing behavior: routing events
+// and interactions, building the Block Kit order modal, and turning
+// submissions into dispatcher orders. store and dispatcher hold the
+// persistence and barista-routing concerns respectively; this package is
+// the glue between them and the Slack APIs.
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/slack-coffeebot/dispatcher"
+	"github.com/shiimaxx/slack-coffeebot/store"
+	"github.com/slack-go/slack"
+)
+
+// Server holds the dependencies and configuration needed to serve Coffeebot's
+// Slack events and interactions.
+type Server struct {
+	router      *http.ServeMux
+	port        string
+	logger      *log.Logger
+	slackClient *slack.Client
+	botID       string
+	store       store.Store
+	dispatcher  *dispatcher.Dispatcher
+
+	// signingSecret is the Slack app's signing secret, used to verify
+	// request authenticity in place of the deprecated verification token.
+	signingSecret string
+
+	// clientCertHeader, if set, names a header that a reverse proxy
+	// populates with the verified client certificate DN. Signature
+	// verification is skipped only when that header is present AND equal
+	// to clientCertTrustedDN.
+	clientCertHeader string
+
+	// clientCertTrustedDN is the expected value of clientCertHeader. Both
+	// must be set for the mTLS escape hatch to apply.
+	clientCertTrustedDN string
+
+	// leadTime is how far in advance, relative to the user's local time, a
+	// delivery must be requested.
+	leadTime time.Duration
+
+	tzMu    sync.Mutex
+	tzCache map[string]*time.Location
+}
+
+// Config holds Server's dependencies and configuration.
+type Config struct {
+	Router      *http.ServeMux
+	Port        string
+	Logger      *log.Logger
+	SlackClient *slack.Client
+	BotID       string
+	Store       store.Store
+	Dispatcher  *dispatcher.Dispatcher
+
+	// SigningSecret is the Slack app's signing secret, used to verify
+	// request authenticity in place of the deprecated verification token.
+	SigningSecret string
+
+	// ClientCertHeader and ClientCertTrustedDN, if both set, name a header a
+	// reverse proxy populates with the verified client certificate DN and
+	// the DN it must equal for the mTLS escape hatch around signature
+	// verification to apply.
+	ClientCertHeader    string
+	ClientCertTrustedDN string
+
+	// LeadTime is how far in advance, relative to the user's local time, a
+	// delivery must be requested.
+	LeadTime time.Duration
+}
+
+// New builds a Server from cfg.
+func New(cfg Config) *Server {
+	return &Server{
+		router:              cfg.Router,
+		port:                cfg.Port,
+		logger:              cfg.Logger,
+		slackClient:         cfg.SlackClient,
+		botID:               cfg.BotID,
+		store:               cfg.Store,
+		dispatcher:          cfg.Dispatcher,
+		signingSecret:       cfg.SigningSecret,
+		clientCertHeader:    cfg.ClientCertHeader,
+		clientCertTrustedDN: cfg.ClientCertTrustedDN,
+		leadTime:            cfg.LeadTime,
+	}
+}
+
+// pendingOrders tracks the message the order modal was opened from, keyed by
+// user ID, until the modal is submitted and the order is persisted to s.store.
+// handleInteraction runs concurrently per-request on both the HTTP and
+// Socket Mode transports, so access is guarded by pendingOrdersMu.
+var (
+	pendingOrdersMu sync.Mutex
+	pendingOrders   = make(map[string]map[string]string)
+)
+
+func (s *Server) routes() {
+	s.router.HandleFunc("/slack/message_actions", s.messageActionHandler())
+}
+
+var actionOrder = "coffee_order"
+
+func (s *Server) listenAndResponse() {
+	rtm := s.slackClient.NewRTM()
+
+	// Start listening slack events
+	go rtm.ManageConnection()
+
+	// Handle slack events
+	for msg := range rtm.IncomingEvents {
+		switch ev := msg.Data.(type) {
+		case *slack.MessageEvent:
+			if err := s.handleMessageEvent(ev); err != nil {
+				log.Printf("[ERROR] Failed to handle message: %s", err)
+			}
+		}
+	}
+}
+
+func (s *Server) handleMessageEvent(ev *slack.MessageEvent) error {
+	// Only response mention to bot. Ignore else.
+	if !strings.HasPrefix(ev.Msg.Text, fmt.Sprintf("<@%s> ", s.botID)) {
+		log.Print(ev.Msg.Text)
+		log.Printf("%s %s", ev.Channel, fmt.Sprintf("<@%s> ", s.botID))
+		return nil
+	}
+
+	// Parse message
+	m := strings.Split(strings.TrimSpace(ev.Msg.Text), " ")[1:]
+	if len(m) == 0 {
+		return nil
+	}
+
+	switch m[0] {
+	case "history":
+		return s.handleHistoryCommand(ev)
+	case "queue":
+		return s.handleQueueCommand(ev)
+	case "order":
+		// handled below
+	default:
+		log.Printf("%s %s", ev.Channel, m[0])
+		return nil
+	}
+
+	options := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, "I am Coffeebot :robot_face:, and I'm here to help bring you fresh coffee :coffee:", false, false),
+			nil, nil,
+		),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(actionOrder, actionOrder, slack.NewTextBlockObject(slack.PlainTextType, ":coffee: Order Coffee", true, false)),
+		),
+	)
+
+	if _, _, err := s.slackClient.PostMessage(ev.Channel, options); err != nil {
+		return fmt.Errorf("failed to post message: %s", err)
+	}
+
+	return nil
+}
+
+// historyLimit is the number of past orders shown by the "history" command.
+const historyLimit = 5
+
+func (s *Server) handleHistoryCommand(ev *slack.MessageEvent) error {
+	orders, err := s.store.GetByUser(ev.User, historyLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load order history: %s", err)
+	}
+
+	attachment := slack.Attachment{
+		Title: "Your recent orders",
+		Color: "#3AA3E3",
+	}
+	if len(orders) == 0 {
+		attachment.Text = "You haven't ordered any coffee yet."
+	}
+	for _, o := range orders {
+		attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+			Title: fmt.Sprintf("%s (%s)", o.CoffeeType, o.Status),
+			Value: o.SubmittedAt.Format("2006-01-02 15:04"),
+			Short: true,
+		})
+	}
+
+	options := slack.MsgOptionAttachments(attachment)
+	if _, _, err := s.slackClient.PostMessage(ev.Channel, options); err != nil {
+		return fmt.Errorf("failed to post message: %s", err)
+	}
+
+	return nil
+}
+
+func (s *Server) handleQueueCommand(ev *slack.MessageEvent) error {
+	pending, err := s.store.ListPending()
+	if err != nil {
+		return fmt.Errorf("failed to load pending queue: %s", err)
+	}
+
+	cutoff := time.Now().Add(time.Hour)
+	var dueSoon []store.Order
+	for _, o := range pending {
+		if o.DeliverAt.Before(cutoff) {
+			dueSoon = append(dueSoon, o)
+		}
+	}
+
+	attachment := slack.Attachment{
+		Title: "Orders due in the next hour",
+		Color: "#3AA3E3",
+	}
+	if len(dueSoon) == 0 {
+		attachment.Text = "Nothing in the queue for the next hour."
+	}
+	for _, o := range dueSoon {
+		attachment.Fields = append(attachment.Fields, slack.AttachmentField{
+			Title: fmt.Sprintf("%s for <@%s>", o.CoffeeType, o.UserID),
+			Value: o.DeliverAt.Format("15:04"),
+			Short: true,
+		})
+	}
+
+	options := slack.MsgOptionAttachments(attachment)
+	if _, _, err := s.slackClient.PostMessage(ev.Channel, options); err != nil {
+		return fmt.Errorf("failed to post message: %s", err)
+	}
+
+	return nil
+}
+
+func (s *Server) messageActionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			log.Print("invalid method: ", r.Method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		buf, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			log.Print("read request body failed: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.verifySignature(r, buf); err != nil {
+			log.Print("signature verification failed: ", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		jsonStr, err := url.QueryUnescape(string(buf)[8:])
+		if err != nil {
+			log.Printf("[ERROR] Failed to unespace request body: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// var message slack.AttachmentActionCallback // DEPRECATED
+		var message slack.InteractionCallback
+		if err := json.Unmarshal([]byte(jsonStr), &message); err != nil {
+			log.Print("json unmarshal message failed: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		result, err := s.handleInteraction(message)
+		if err != nil {
+			log.Print("[ERROR] Failed to handle interaction: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if result != nil {
+			w.Header().Add("Content-type", "application/json")
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// viewSubmissionResponse is the response_action payload Slack expects back
+// from a view_submission when a field fails validation.
+type viewSubmissionResponse struct {
+	ResponseAction string            `json:"response_action"`
+	Errors         map[string]string `json:"errors"`
+}
+
+// handleInteraction applies a block_actions or view_submission interaction
+// callback and returns the response_action payload the caller should send
+// back to Slack, or nil if a bare acknowledgement is enough. It is shared by
+// both the HTTP and Socket Mode transports.
+func (s *Server) handleInteraction(message slack.InteractionCallback) (*viewSubmissionResponse, error) {
+	switch message.Type {
+	case slack.InteractionTypeBlockActions:
+		if len(message.ActionCallback.BlockActions) == 0 {
+			return nil, nil
+		}
+		action := message.ActionCallback.BlockActions[0]
+
+		switch action.ActionID {
+		case dispatcher.ActionAccept, dispatcher.ActionReject:
+			err := s.dispatcher.HandleResponse(message.User.ID, action.Value, action.ActionID == dispatcher.ActionAccept)
+			return nil, err
+		case dispatcher.ActionDelivered:
+			err := s.dispatcher.Deliver(message.User.ID, action.Value)
+			return nil, err
+		case actionOrder:
+			// handled below
+		default:
+			return nil, nil
+		}
+
+		pendingOrdersMu.Lock()
+		if _, ok := pendingOrders[message.User.ID]["MessageTs"]; !ok {
+			pendingOrders[message.User.ID] = make(map[string]string)
+		}
+		pendingOrders[message.User.ID]["MessageTs"] = message.Message.Timestamp
+		pendingOrders[message.User.ID]["order_channel"] = message.Channel.ID
+		pendingOrdersMu.Unlock()
+
+		modal := makeOrderModal(message.User.ID)
+		if _, err := s.slackClient.OpenViewContext(context.TODO(), message.TriggerID, *modal); err != nil {
+			return nil, fmt.Errorf("open view failed: %s", err)
+		}
+
+		text := slack.MsgOptionText(":pencil: Taking your order...", false)
+		if _, _, _, err := s.slackClient.UpdateMessage(message.Channel.ID, message.Message.Timestamp, text); err != nil {
+			log.Print("update message failed: ", err)
+		}
+
+		return nil, nil
+
+	case slack.InteractionTypeViewSubmission:
+		values := message.View.State.Values
+		userID := message.View.CallbackID
+		t := values[blockDeliveryTime][actionDeliveryTime].SelectedTime
+		deliverAt, err := s.validateTime(userID, t)
+		if err != nil {
+			return &viewSubmissionResponse{
+				ResponseAction: "errors",
+				Errors:         map[string]string{blockDeliveryTime: err.Error()},
+			}, nil
+		}
+
+		pendingOrdersMu.Lock()
+		orderChannel := pendingOrders[userID]["order_channel"]
+		messageTs := pendingOrders[userID]["MessageTs"]
+		delete(pendingOrders, userID)
+		pendingOrdersMu.Unlock()
+
+		o, err := s.store.Create(store.Order{
+			UserID:        userID,
+			Channel:       orderChannel,
+			CoffeeType:    values[blockCoffeeType][actionCoffeeType].SelectedOption.Value,
+			Customization: values[blockCustomization][actionCustomization].Value,
+			DeliverAt:     deliverAt,
+			MessageTs:     messageTs,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to save order: %s", err)
+		}
+
+		// o stays StatusPending — so it shows up in @bot queue — until a
+		// barista actually accepts it, at which point the dispatcher moves
+		// it to StatusBrewing.
+		options := slack.MsgOptionBlocks(
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, ":white_check_mark: Order received! Looking for a barista...", false, false),
+				nil, nil,
+			),
+		)
+		if _, _, err := s.slackClient.PostMessage(o.Channel, options); err != nil {
+			log.Print("[ERROR] Failed to post message")
+		}
+
+		go func() {
+			if err := s.dispatcher.Dispatch(o); err != nil {
+				log.Print("[ERROR] Failed to dispatch order: ", err)
+			}
+		}()
+
+		return nil, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,55 @@
+package bot
+
+import "github.com/slack-go/slack"
+
+const (
+	blockCoffeeType     = "coffee_type_block"
+	actionCoffeeType    = "coffee_type_select"
+	blockCustomization  = "customization_block"
+	actionCustomization = "customization_input"
+	blockDeliveryTime   = "delivery_time_block"
+	actionDeliveryTime  = "delivery_time_picker"
+)
+
+func coffeeTypeOptions() []*slack.OptionBlockObject {
+	types := []struct{ label, value string }{
+		{"Cappuccino", "cappuccino"},
+		{"Latte", "latte"},
+		{"Pour Over", "pourOver"},
+		{"Cold Brew", "coldBrew"},
+	}
+
+	options := make([]*slack.OptionBlockObject, len(types))
+	for i, t := range types {
+		options[i] = slack.NewOptionBlockObject(t.value, slack.NewTextBlockObject(slack.PlainTextType, t.label, false, false), nil)
+	}
+	return options
+}
+
+// makeOrderModal builds the view.open modal used to take a coffee order.
+// The view's CallbackID carries the requesting user's ID so the submission
+// handler can match it back up with pendingOrders.
+func makeOrderModal(userID string) *slack.ModalViewRequest {
+	coffeeTypeSelect := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, "Select a drink", false, false), actionCoffeeType, coffeeTypeOptions()...)
+	customizationInput := slack.NewPlainTextInputBlockElement(nil, actionCustomization)
+	customizationInput.Multiline = true
+	timePicker := slack.NewTimePickerBlockElement(actionDeliveryTime)
+
+	customizationBlock := slack.NewInputBlock(blockCustomization, slack.NewTextBlockObject(slack.PlainTextType, "Customization orders", false, false), nil, customizationInput)
+	customizationBlock.Optional = true
+
+	return &slack.ModalViewRequest{
+		Type:          slack.VTModal,
+		Title:         slack.NewTextBlockObject(slack.PlainTextType, "Request a coffee", false, false),
+		Submit:        slack.NewTextBlockObject(slack.PlainTextType, "Submit", false, false),
+		CallbackID:    userID,
+		NotifyOnClose: false,
+		Blocks: slack.Blocks{
+			BlockSet: []slack.Block{
+				slack.NewInputBlock(blockCoffeeType, slack.NewTextBlockObject(slack.PlainTextType, "Coffee Type", false, false), nil, coffeeTypeSelect),
+				customizationBlock,
+				slack.NewInputBlock(blockDeliveryTime, slack.NewTextBlockObject(slack.PlainTextType, "Time to deliver", false, false), nil, timePicker),
+			},
+		},
+	}
+}
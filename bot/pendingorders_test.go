@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestPendingOrdersConcurrentAccess exercises the same lock/read/write/delete
+// sequence handleInteraction performs on pendingOrders, from many goroutines
+// at once, as happens when concurrent Slack interactions hit the process.
+// Run with -race: before pendingOrdersMu existed, this reliably tripped
+// "concurrent map writes".
+func TestPendingOrdersConcurrentAccess(t *testing.T) {
+	const n = 50
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("U%d", i)
+
+			pendingOrdersMu.Lock()
+			if _, ok := pendingOrders[userID]["MessageTs"]; !ok {
+				pendingOrders[userID] = make(map[string]string)
+			}
+			pendingOrders[userID]["MessageTs"] = "1234.5678"
+			pendingOrders[userID]["order_channel"] = "C1"
+			pendingOrdersMu.Unlock()
+
+			pendingOrdersMu.Lock()
+			orderChannel := pendingOrders[userID]["order_channel"]
+			messageTs := pendingOrders[userID]["MessageTs"]
+			delete(pendingOrders, userID)
+			pendingOrdersMu.Unlock()
+
+			if orderChannel != "C1" || messageTs != "1234.5678" {
+				t.Errorf("unexpected pendingOrders contents for %s: channel=%q ts=%q", userID, orderChannel, messageTs)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	pendingOrdersMu.Lock()
+	defer pendingOrdersMu.Unlock()
+	if len(pendingOrders) != 0 {
+		t.Fatalf("expected pendingOrders to be empty after all deletes, got %d entries", len(pendingOrders))
+	}
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	slackSignatureHeader = "X-Slack-Signature"
+	slackTimestampHeader = "X-Slack-Request-Timestamp"
+	signatureVersion     = "v0"
+	maxClockSkew         = 5 * time.Minute
+)
+
+// verifySignature checks that r carries a valid Slack signing-secret
+// signature over body, the raw (not unescaped) request body.
+//
+// If both s.clientCertHeader and s.clientCertTrustedDN are set, and the
+// request carries that header with exactly that value, verification is
+// skipped: the bot is assumed to sit behind a reverse proxy that terminates
+// client certificates and forwards the verified certificate DN in that
+// header. The header's mere presence is not enough — its value must match
+// the configured DN, or an attacker who can reach this handler directly
+// (proxy misconfiguration, missing header stripping) could forge it to
+// bypass verification entirely.
+func (s *Server) verifySignature(r *http.Request, body []byte) error {
+	if s.clientCertHeader != "" && s.clientCertTrustedDN != "" {
+		got := r.Header.Get(s.clientCertHeader)
+		if got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(s.clientCertTrustedDN)) == 1 {
+			return nil
+		}
+	}
+
+	ts := r.Header.Get(slackTimestampHeader)
+	sig := r.Header.Get(slackSignatureHeader)
+	if ts == "" || sig == "" {
+		return fmt.Errorf("missing signature headers")
+	}
+
+	unixTs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp header: %s", err)
+	}
+	if math.Abs(time.Since(time.Unix(unixTs, 0)).Seconds()) > maxClockSkew.Seconds() {
+		return fmt.Errorf("request timestamp too old, possible replay attack")
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.signingSecret))
+	mac.Write([]byte(signatureVersion + ":" + ts + ":"))
+	mac.Write(body)
+	expected := signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
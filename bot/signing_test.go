@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedRequest(t *testing.T, secret string, body []byte, ts time.Time) *http.Request {
+	t.Helper()
+
+	tsStr := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signatureVersion + ":" + tsStr + ":"))
+	mac.Write(body)
+	sig := signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+
+	r := httptest.NewRequest(http.MethodPost, "/slack/message_actions", nil)
+	r.Header.Set(slackTimestampHeader, tsStr)
+	r.Header.Set(slackSignatureHeader, sig)
+	return r
+}
+
+func TestVerifySignatureValid(t *testing.T) {
+	s := &Server{signingSecret: "shhh"}
+	body := []byte(`payload=%7B%7D`)
+
+	r := signedRequest(t, s.signingSecret, body, time.Now())
+	if err := s.verifySignature(r, body); err != nil {
+		t.Fatalf("expected valid signature to verify, got: %s", err)
+	}
+}
+
+func TestVerifySignatureWrongSecret(t *testing.T) {
+	s := &Server{signingSecret: "shhh"}
+	body := []byte(`payload=%7B%7D`)
+
+	r := signedRequest(t, "wrong-secret", body, time.Now())
+	if err := s.verifySignature(r, body); err == nil {
+		t.Fatal("expected signature mismatch to fail verification")
+	}
+}
+
+func TestVerifySignatureTamperedBody(t *testing.T) {
+	s := &Server{signingSecret: "shhh"}
+	r := signedRequest(t, s.signingSecret, []byte(`payload=%7B%7D`), time.Now())
+
+	if err := s.verifySignature(r, []byte(`payload=%7B%22x%22%3A1%7D`)); err == nil {
+		t.Fatal("expected a tampered body to fail verification")
+	}
+}
+
+func TestVerifySignatureStaleTimestamp(t *testing.T) {
+	s := &Server{signingSecret: "shhh"}
+	body := []byte(`payload=%7B%7D`)
+
+	r := signedRequest(t, s.signingSecret, body, time.Now().Add(-10*time.Minute))
+	if err := s.verifySignature(r, body); err == nil {
+		t.Fatal("expected a stale timestamp to be rejected as a possible replay")
+	}
+}
+
+func TestVerifySignatureMissingHeaders(t *testing.T) {
+	s := &Server{signingSecret: "shhh"}
+	body := []byte(`payload=%7B%7D`)
+
+	r := httptest.NewRequest(http.MethodPost, "/slack/message_actions", nil)
+	if err := s.verifySignature(r, body); err == nil {
+		t.Fatal("expected missing signature headers to be rejected")
+	}
+}
+
+func TestVerifySignatureClientCertBypassRequiresMatchingDN(t *testing.T) {
+	s := &Server{
+		signingSecret:       "shhh",
+		clientCertHeader:    "X-Client-Cert-DN",
+		clientCertTrustedDN: "CN=trusted-proxy",
+	}
+	body := []byte(`payload=%7B%7D`)
+	r := httptest.NewRequest(http.MethodPost, "/slack/message_actions", nil)
+
+	// No signature headers at all: would fail verifySignature's HMAC path,
+	// but should be let through once the trusted proxy header matches.
+	r.Header.Set("X-Client-Cert-DN", "CN=trusted-proxy")
+	if err := s.verifySignature(r, body); err != nil {
+		t.Fatalf("expected a matching trusted DN to bypass verification, got: %s", err)
+	}
+}
+
+func TestVerifySignatureClientCertBypassRejectsWrongDN(t *testing.T) {
+	s := &Server{
+		signingSecret:       "shhh",
+		clientCertHeader:    "X-Client-Cert-DN",
+		clientCertTrustedDN: "CN=trusted-proxy",
+	}
+	body := []byte(`payload=%7B%7D`)
+	r := httptest.NewRequest(http.MethodPost, "/slack/message_actions", nil)
+
+	r.Header.Set("X-Client-Cert-DN", "CN=some-other-caller")
+	if err := s.verifySignature(r, body); err == nil {
+		t.Fatal("expected a mismatched client cert DN to fall through to normal HMAC verification and fail")
+	}
+}
+
+func TestVerifySignatureClientCertBypassIgnoredWithoutTrustedDNConfigured(t *testing.T) {
+	// clientCertTrustedDN is unset: the header's mere presence must not be
+	// enough to bypass verification (the bug this test guards against).
+	s := &Server{
+		signingSecret:    "shhh",
+		clientCertHeader: "X-Client-Cert-DN",
+	}
+	body := []byte(`payload=%7B%7D`)
+	r := httptest.NewRequest(http.MethodPost, "/slack/message_actions", nil)
+
+	r.Header.Set("X-Client-Cert-DN", "anything")
+	if err := s.verifySignature(r, body); err == nil {
+		t.Fatal("expected the bypass to be inert when clientCertTrustedDN is not configured")
+	}
+}
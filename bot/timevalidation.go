@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var errMalformedTime = errors.New("time must be in hh:mm format")
+var errBelowLeadTime = errors.New("time does not allow enough lead time")
+
+// validateTime parses an hh:mm delivery time in userID's local timezone. If
+// that time has already passed today, it rolls forward to tomorrow instead
+// of rejecting the order outright, so requests placed near midnight for the
+// next morning are accepted. Rolling forward always lands after now, even
+// across a DST transition, so there is no separate "time already passed"
+// error: the only remaining check is that the result is at least s.leadTime
+// away from now.
+func (s *Server) validateTime(userID, t string) (time.Time, error) {
+	const format = "15:04"
+	parsedTime, err := time.Parse(format, t)
+	if err != nil {
+		return time.Time{}, errMalformedTime
+	}
+
+	loc, err := s.userTimezone(userID)
+	if err != nil {
+		log.Print("[WARN] failed to resolve user timezone, falling back to UTC: ", err)
+		loc = time.UTC
+	}
+
+	now := time.Now().In(loc)
+	deliverAt := time.Date(now.Year(), now.Month(), now.Day(), parsedTime.Hour(), parsedTime.Minute(), 0, 0, loc)
+	if deliverAt.Before(now) {
+		deliverAt = deliverAt.AddDate(0, 0, 1)
+	}
+
+	if deliverAt.Before(now.Add(s.leadTime)) {
+		return time.Time{}, errBelowLeadTime
+	}
+
+	return deliverAt, nil
+}
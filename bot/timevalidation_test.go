@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTimeMalformedFormat(t *testing.T) {
+	s := &Server{leadTime: 0}
+
+	if _, err := s.validateTime("U1", "not-a-time"); err != errMalformedTime {
+		t.Fatalf("expected errMalformedTime, got %v", err)
+	}
+}
+
+func TestValidateTimeRollsPastTimeOverToTomorrow(t *testing.T) {
+	loc := time.UTC
+	s := &Server{leadTime: 0, tzCache: map[string]*time.Location{"U1": loc}}
+
+	now := time.Now().In(loc)
+	// Midnight today is always an hh:mm that has already passed today;
+	// now-1h would format as 23:xx, a later time today, between 00:00 and 01:00.
+	past := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	deliverAt, err := s.validateTime("U1", past.Format("15:04"))
+	if err != nil {
+		t.Fatalf("validateTime: %s", err)
+	}
+
+	if !deliverAt.After(now) {
+		t.Fatalf("expected a past time to roll forward to tomorrow and land after now, got %v (now %v)", deliverAt, now)
+	}
+	if deliverAt.YearDay() == now.YearDay() && deliverAt.Year() == now.Year() {
+		t.Fatalf("expected rollover to land on the following day, got same day: %v", deliverAt)
+	}
+}
+
+func TestValidateTimeBelowLeadTime(t *testing.T) {
+	loc := time.UTC
+	s := &Server{leadTime: time.Hour, tzCache: map[string]*time.Location{"U1": loc}}
+
+	now := time.Now().In(loc)
+	soon := now.Add(5 * time.Minute)
+	if _, err := s.validateTime("U1", soon.Format("15:04")); err != errBelowLeadTime {
+		t.Fatalf("expected errBelowLeadTime, got %v", err)
+	}
+}
+
+func TestValidateTimeAcceptsTimeAtOrBeyondLeadTime(t *testing.T) {
+	loc := time.UTC
+	s := &Server{leadTime: time.Hour, tzCache: map[string]*time.Location{"U1": loc}}
+
+	now := time.Now().In(loc)
+	later := now.Add(2 * time.Hour)
+	deliverAt, err := s.validateTime("U1", later.Format("15:04"))
+	if err != nil {
+		t.Fatalf("validateTime: %s", err)
+	}
+	if deliverAt.Before(now.Add(s.leadTime)) {
+		t.Fatalf("expected deliverAt %v to satisfy the lead time from %v", deliverAt, now)
+	}
+}
+
+// TestValidateTimeCrossMidnightUsesRequesterTimezone exercises a user whose
+// local clock is, whenever UTC is past 10:00, on a later calendar day than
+// UTC, to make sure
+// validateTime's "is this before now" comparison runs entirely in the
+// requester's timezone rather than the server's.
+func TestValidateTimeCrossMidnightUsesRequesterTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Pacific/Kiritimati") // UTC+14, a day ahead of UTC from 10:00 UTC on
+	if err != nil {
+		t.Skipf("tzdata unavailable in this environment: %s", err)
+	}
+	s := &Server{leadTime: 0, tzCache: map[string]*time.Location{"U1": loc}}
+
+	now := time.Now().In(loc)
+	later := now.Add(2 * time.Hour)
+	deliverAt, err := s.validateTime("U1", later.Format("15:04"))
+	if err != nil {
+		t.Fatalf("validateTime: %s", err)
+	}
+	if deliverAt.Location().String() != loc.String() {
+		t.Fatalf("expected deliverAt to stay in the requester's timezone %s, got %s", loc, deliverAt.Location())
+	}
+}
+
+// TestValidateTimeDSTTransitionStillRollsForward exercises a timezone that
+// observes DST (so its UTC offset shifts through the year) and checks that a
+// time an hour before now comes back as a delivery time after now. It runs at
+// the current date, so it crosses an actual DST transition only when run on
+// the day before one.
+func TestValidateTimeDSTTransitionStillRollsForward(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata unavailable in this environment: %s", err)
+	}
+	s := &Server{leadTime: 0, tzCache: map[string]*time.Location{"U1": loc}}
+
+	now := time.Now().In(loc)
+	past := now.Add(-time.Hour)
+	deliverAt, err := s.validateTime("U1", past.Format("15:04"))
+	if err != nil {
+		t.Fatalf("validateTime: %s", err)
+	}
+	if !deliverAt.After(now) {
+		t.Fatalf("expected rollover to land after now even across a DST-observing zone, got %v (now %v)", deliverAt, now)
+	}
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+)
+
+// userTimezone resolves userID's Slack timezone via users.info, caching the
+// result so repeat submissions don't re-fetch it.
+func (s *Server) userTimezone(userID string) (*time.Location, error) {
+	s.tzMu.Lock()
+	if loc, ok := s.tzCache[userID]; ok {
+		s.tzMu.Unlock()
+		return loc, nil
+	}
+	s.tzMu.Unlock()
+
+	user, err := s.slackClient.GetUserInfo(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user info: %s", err)
+	}
+
+	loc, err := time.LoadLocation(user.TZ)
+	if err != nil {
+		loc = time.FixedZone(user.TZ, user.TZOffset)
+	}
+
+	s.tzMu.Lock()
+	if s.tzCache == nil {
+		s.tzCache = make(map[string]*time.Location)
+	}
+	s.tzCache[userID] = loc
+	s.tzMu.Unlock()
+
+	return loc, nil
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// Transport delivers Slack events and interactions to the server's handlers.
+// httpTransport serves them over the public HTTP callbacks Slack posts to;
+// socketTransport pulls them over a Socket Mode websocket instead, so the
+// bot can run behind NAT without exposing a public port.
+type Transport interface {
+	Run(ctx context.Context) error
+}
+
+// httpTransport is the original transport: RTM for events, and public HTTP
+// callbacks for interactions.
+type httpTransport struct {
+	s *Server
+}
+
+// NewHTTPTransport wraps s in a Transport that serves RTM events and public
+// HTTP interaction callbacks.
+func NewHTTPTransport(s *Server) Transport {
+	return &httpTransport{s: s}
+}
+
+func (t *httpTransport) Run(ctx context.Context) error {
+	go t.s.listenAndResponse()
+
+	t.s.routes()
+
+	srv := &http.Server{Addr: ":" + t.s.port, Handler: t.s.router}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// socketTransport consumes events_api and interactive envelopes over a
+// Socket Mode connection and fans them into the same handler functions the
+// HTTP transport uses. slash_commands envelopes are acknowledged and logged,
+// and are not otherwise handled. socketmode.Client already
+// reconnects with exponential backoff internally.
+type socketTransport struct {
+	s      *Server
+	client *socketmode.Client
+}
+
+// NewSocketTransport wraps s in a Transport that runs over Socket Mode.
+// s's slack client must have been constructed with slack.OptionAppLevelToken
+// so the websocket handshake can authenticate.
+func NewSocketTransport(s *Server) Transport {
+	client := socketmode.New(s.slackClient, socketmode.OptionLog(s.logger))
+	return &socketTransport{s: s, client: client}
+}
+
+func (t *socketTransport) Run(ctx context.Context) error {
+	go t.handleEvents(ctx)
+	return t.client.RunContext(ctx)
+}
+
+func (t *socketTransport) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-t.client.Events:
+			switch evt.Type {
+			case socketmode.EventTypeEventsAPI:
+				payload, ok := evt.Data.(slackevents.EventsAPIEvent)
+				if !ok {
+					continue
+				}
+				t.client.Ack(*evt.Request)
+
+				if payload.Type == slackevents.CallbackEvent {
+					if ev, ok := payload.InnerEvent.Data.(*slackevents.MessageEvent); ok {
+						if err := t.s.handleMessageEvent(toRTMMessageEvent(ev)); err != nil {
+							log.Printf("[ERROR] Failed to handle message: %s", err)
+						}
+					}
+				}
+
+			case socketmode.EventTypeInteractive:
+				callback, ok := evt.Data.(slack.InteractionCallback)
+				if !ok {
+					continue
+				}
+
+				result, err := t.s.handleInteraction(callback)
+				if err != nil {
+					log.Printf("[ERROR] Failed to handle interaction: %s", err)
+				}
+				if result != nil {
+					t.client.Ack(*evt.Request, result)
+				} else {
+					t.client.Ack(*evt.Request)
+				}
+
+			case socketmode.EventTypeSlashCommand:
+				cmd, ok := evt.Data.(slack.SlashCommand)
+				if !ok {
+					continue
+				}
+				t.client.Ack(*evt.Request)
+				log.Printf("[INFO] ignoring unsupported slash command: %s", cmd.Command)
+			}
+		}
+	}
+}
+
+// toRTMMessageEvent adapts a socket-mode slackevents.MessageEvent to the
+// *slack.MessageEvent shape handleMessageEvent already knows how to handle.
+func toRTMMessageEvent(ev *slackevents.MessageEvent) *slack.MessageEvent {
+	return &slack.MessageEvent{
+		Msg: slack.Msg{
+			Channel:   ev.Channel,
+			User:      ev.User,
+			Text:      ev.Text,
+			Timestamp: ev.TimeStamp,
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package dispatcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseBaristas parses a barista pool spec of the form
+// "<userID>:<capacity>:<skill1>,<skill2>;<userID>:<capacity>:*", where a
+// skill list of "*" (or omitted) means the barista accepts any coffee type.
+func ParseBaristas(spec string) ([]Barista, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	var baristas []Barista
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid barista spec: %q", entry)
+		}
+
+		capacity, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid barista spec: %q", entry)
+		}
+
+		var skills []string
+		if len(fields) > 2 && fields[2] != "" && fields[2] != "*" {
+			skills = strings.Split(fields[2], ",")
+		}
+
+		baristas = append(baristas, Barista{
+			UserID:   fields[0],
+			Capacity: capacity,
+			Skills:   skills,
+		})
+	}
+
+	return baristas, nil
+}
@@ -0,0 +1,272 @@
+// Package dispatcher routes submitted orders to the least-loaded eligible
+// barista and tracks accept/reject responses through to delivery.
+package dispatcher
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/slack-coffeebot/store"
+	"github.com/slack-go/slack"
+)
+
+// ActionAccept, ActionReject, and ActionDelivered are the action IDs on the
+// buttons posted to a barista's IM, exported so callers can recognize them
+// in interaction callbacks before routing to HandleResponse/Deliver.
+const (
+	ActionAccept    = "barista_accept"
+	ActionReject    = "barista_reject"
+	ActionDelivered = "barista_delivered"
+)
+
+// Barista is a pool member eligible to brew orders.
+type Barista struct {
+	UserID   string
+	Capacity int
+	// Skills lists the coffee types this barista can make. An empty slice
+	// means the barista accepts any coffee type.
+	Skills []string
+}
+
+func (b Barista) canMake(coffeeType string) bool {
+	if len(b.Skills) == 0 {
+		return true
+	}
+	for _, s := range b.Skills {
+		if s == coffeeType {
+			return true
+		}
+	}
+	return false
+}
+
+// offer tracks an order currently awaiting a response from one barista.
+type offer struct {
+	order    store.Order
+	barista  string
+	excluded map[string]bool
+	timer    *time.Timer
+}
+
+// assignment tracks an order currently being brewed by a barista.
+type assignment struct {
+	order   store.Order
+	barista string
+}
+
+// Dispatcher routes orders to baristas and escalates to a fallback channel
+// if nobody accepts in time.
+type Dispatcher struct {
+	slackClient     *slack.Client
+	store           store.Store
+	baristas        []Barista
+	fallbackChannel string
+	acceptTimeout   time.Duration
+
+	mu       sync.Mutex
+	load     map[string]int
+	offers   map[string]*offer
+	assigned map[string]assignment // orderID -> assignment, while brewing
+}
+
+// New builds a Dispatcher over the given barista pool. fallbackChannel is
+// used when no eligible barista accepts an order within acceptTimeout.
+func New(slackClient *slack.Client, st store.Store, baristas []Barista, fallbackChannel string, acceptTimeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		slackClient:     slackClient,
+		store:           st,
+		baristas:        baristas,
+		fallbackChannel: fallbackChannel,
+		acceptTimeout:   acceptTimeout,
+		load:            make(map[string]int),
+		offers:          make(map[string]*offer),
+		assigned:        make(map[string]assignment),
+	}
+}
+
+// Dispatch picks the least-loaded eligible barista for o and opens an IM
+// offering them the order. If nobody is eligible, o is escalated
+// immediately.
+func (d *Dispatcher) Dispatch(o store.Order) error {
+	return d.offerTo(o, map[string]bool{})
+}
+
+// HandleResponse applies a barista's accept or reject of orderID.
+func (d *Dispatcher) HandleResponse(baristaID, orderID string, accepted bool) error {
+	d.mu.Lock()
+	off, ok := d.offers[orderID]
+	if !ok || off.barista != baristaID {
+		d.mu.Unlock()
+		return fmt.Errorf("no pending offer for order %s from barista %s", orderID, baristaID)
+	}
+	off.timer.Stop()
+	delete(d.offers, orderID)
+	d.mu.Unlock()
+
+	if accepted {
+		d.mu.Lock()
+		d.load[baristaID]++
+		d.assigned[orderID] = assignment{order: off.order, barista: baristaID}
+		d.mu.Unlock()
+
+		if err := d.store.UpdateStatus(orderID, store.StatusBrewing); err != nil {
+			return fmt.Errorf("update order status: %s", err)
+		}
+
+		if err := d.postDeliveredButton(baristaID, orderID); err != nil {
+			log.Printf("[ERROR] Failed to post delivered button for order %s: %s", orderID, err)
+		}
+
+		return d.notifyRequester(off.order, fmt.Sprintf(":coffee: <@%s> is brewing your order!", baristaID))
+	}
+
+	off.excluded[baristaID] = true
+	return d.offerTo(off.order, off.excluded)
+}
+
+// Deliver marks orderID delivered and frees the barista's capacity, in
+// response to the barista pressing "Mark Delivered".
+func (d *Dispatcher) Deliver(baristaID, orderID string) error {
+	d.mu.Lock()
+	a, ok := d.assigned[orderID]
+	if !ok || a.barista != baristaID {
+		d.mu.Unlock()
+		return fmt.Errorf("order %s is not assigned to barista %s", orderID, baristaID)
+	}
+	delete(d.assigned, orderID)
+	if d.load[baristaID] > 0 {
+		d.load[baristaID]--
+	}
+	d.mu.Unlock()
+
+	if err := d.store.UpdateStatus(orderID, store.StatusDelivered); err != nil {
+		return fmt.Errorf("update order status: %s", err)
+	}
+
+	return d.notifyRequester(a.order, ":tada: Your coffee has been delivered!")
+}
+
+// postDeliveredButton opens (or reuses) the barista's IM and posts a button
+// that marks orderID delivered, freeing the barista's capacity when pressed.
+func (d *Dispatcher) postDeliveredButton(baristaID, orderID string) error {
+	channel, _, _, err := d.slackClient.OpenConversation(&slack.OpenConversationParameters{Users: []string{baristaID}})
+	if err != nil {
+		return fmt.Errorf("open conversation with barista %s: %s", baristaID, err)
+	}
+
+	options := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, ":white_check_mark: Order accepted. Press below once it's delivered.", false, false),
+			nil, nil,
+		),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(ActionDelivered, orderID, slack.NewTextBlockObject(slack.PlainTextType, "Mark Delivered", true, false)),
+		),
+	)
+	_, _, err = d.slackClient.PostMessage(channel.ID, options)
+	return err
+}
+
+// offerTo offers o to the least-loaded eligible barista not in excluded,
+// escalating if none remain.
+func (d *Dispatcher) offerTo(o store.Order, excluded map[string]bool) error {
+	candidate, ok := d.leastLoadedCandidate(o.CoffeeType, excluded)
+	if !ok {
+		return d.escalate(o)
+	}
+
+	channel, _, _, err := d.slackClient.OpenConversation(&slack.OpenConversationParameters{Users: []string{candidate}})
+	if err != nil {
+		return fmt.Errorf("open conversation with barista %s: %s", candidate, err)
+	}
+
+	options := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":bell: New order: *%s* for <@%s>, due %s", o.CoffeeType, o.UserID, o.DeliverAt.Format("15:04")), false, false),
+			nil, nil,
+		),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(ActionAccept, o.ID, slack.NewTextBlockObject(slack.PlainTextType, "Accept", true, false)),
+			slack.NewButtonBlockElement(ActionReject, o.ID, slack.NewTextBlockObject(slack.PlainTextType, "Reject", true, false)),
+		),
+	)
+	if _, _, err := d.slackClient.PostMessage(channel.ID, options); err != nil {
+		return fmt.Errorf("post offer to barista %s: %s", candidate, err)
+	}
+
+	off := &offer{order: o, barista: candidate, excluded: excluded}
+	off.timer = time.AfterFunc(d.acceptTimeout, func() {
+		d.mu.Lock()
+		_, stillPending := d.offers[o.ID]
+		delete(d.offers, o.ID)
+		d.mu.Unlock()
+
+		if !stillPending {
+			return
+		}
+
+		excluded[candidate] = true
+		if err := d.offerTo(o, excluded); err != nil {
+			log.Printf("[ERROR] Failed to re-offer order %s: %s", o.ID, err)
+		}
+	})
+
+	d.mu.Lock()
+	d.offers[o.ID] = off
+	d.mu.Unlock()
+
+	return nil
+}
+
+// leastLoadedCandidate returns the eligible barista (skilled for coffeeType,
+// not in excluded, under capacity) with the lowest current load.
+func (d *Dispatcher) leastLoadedCandidate(coffeeType string, excluded map[string]bool) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var best Barista
+	found := false
+	for _, b := range d.baristas {
+		if excluded[b.UserID] || !b.canMake(coffeeType) || d.load[b.UserID] >= b.Capacity {
+			continue
+		}
+		if !found || d.load[b.UserID] < d.load[best.UserID] {
+			best = b
+			found = true
+		}
+	}
+
+	if !found {
+		return "", false
+	}
+	return best.UserID, true
+}
+
+// escalate posts o to the fallback channel when no barista accepted it.
+func (d *Dispatcher) escalate(o store.Order) error {
+	options := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":rotating_light: No barista available for <@%s>'s %s (due %s)", o.UserID, o.CoffeeType, o.DeliverAt.Format("15:04")), false, false),
+			nil, nil,
+		),
+	)
+	if _, _, err := d.slackClient.PostMessage(d.fallbackChannel, options); err != nil {
+		return fmt.Errorf("post escalation: %s", err)
+	}
+	return nil
+}
+
+func (d *Dispatcher) notifyRequester(o store.Order, text string) error {
+	options := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, text, false, false),
+			nil, nil,
+		),
+	)
+	if _, _, err := d.slackClient.PostMessage(o.Channel, options); err != nil {
+		return fmt.Errorf("notify requester: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/slack-coffeebot/store"
+	"github.com/slack-go/slack"
+)
+
+// fakeStore is a minimal in-memory store.Store, only tracking the status
+// updates dispatcher cares about.
+type fakeStore struct {
+	mu       sync.Mutex
+	statuses map[string]store.Status
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{statuses: make(map[string]store.Status)}
+}
+
+func (s *fakeStore) Create(o store.Order) (store.Order, error) { return o, nil }
+
+func (s *fakeStore) UpdateStatus(id string, status store.Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[id] = status
+	return nil
+}
+
+func (s *fakeStore) statusOf(id string) store.Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statuses[id]
+}
+
+func (s *fakeStore) GetByUser(userID string, limit int) ([]store.Order, error) { return nil, nil }
+func (s *fakeStore) ListPending() ([]store.Order, error)                       { return nil, nil }
+func (s *fakeStore) ListByTimeRange(from, to time.Time) ([]store.Order, error) { return nil, nil }
+func (s *fakeStore) Close() error                                              { return nil }
+
+// fakeSlackAPI serves just enough of the Slack Web API for dispatcher to
+// open IMs and post messages, and records every call so tests can assert on
+// who was messaged.
+type fakeSlackAPI struct {
+	mu    sync.Mutex
+	calls []fakeSlackCall
+}
+
+type fakeSlackCall struct {
+	method  string // "conversations.open" or "chat.postMessage"
+	channel string // users param for conversations.open, channel param for chat.postMessage
+}
+
+func newFakeSlackAPI() *fakeSlackAPI {
+	return &fakeSlackAPI{}
+}
+
+func (f *fakeSlackAPI) server() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/conversations.open", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		users := r.FormValue("users")
+
+		f.mu.Lock()
+		f.calls = append(f.calls, fakeSlackCall{method: "conversations.open", channel: users})
+		f.mu.Unlock()
+
+		fmt.Fprintf(w, `{"ok":true,"channel":{"id":"D-%s"}}`, users)
+	})
+	mux.HandleFunc("/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		channel := r.FormValue("channel")
+
+		f.mu.Lock()
+		f.calls = append(f.calls, fakeSlackCall{method: "chat.postMessage", channel: channel})
+		f.mu.Unlock()
+
+		fmt.Fprintf(w, `{"ok":true,"channel":%q,"ts":"1234.5678"}`, channel)
+	})
+	return httptest.NewServer(mux)
+}
+
+// offersTo returns the "users"/"channel" targets of every conversations.open
+// call made so far, in order.
+func (f *fakeSlackAPI) offersTo() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var targets []string
+	for _, c := range f.calls {
+		if c.method == "conversations.open" {
+			targets = append(targets, c.channel)
+		}
+	}
+	return targets
+}
+
+func (f *fakeSlackAPI) postedTo(channel string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.calls {
+		if c.method == "chat.postMessage" && c.channel == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestDispatcher(t *testing.T, baristas []Barista, fallbackChannel string, acceptTimeout time.Duration) (*Dispatcher, *fakeSlackAPI, *fakeStore) {
+	t.Helper()
+
+	api := newFakeSlackAPI()
+	srv := api.server()
+	t.Cleanup(srv.Close)
+
+	client := slack.New("test-token", slack.OptionAPIURL(srv.URL+"/"))
+	st := newFakeStore()
+
+	return New(client, st, baristas, fallbackChannel, acceptTimeout), api, st
+}
+
+func TestDispatchRejectThenReoffersToNextCandidate(t *testing.T) {
+	baristas := []Barista{
+		{UserID: "B1", Capacity: 1},
+		{UserID: "B2", Capacity: 1},
+	}
+	d, api, _ := newTestDispatcher(t, baristas, "C-fallback", time.Minute)
+
+	o := store.Order{ID: "O1", UserID: "U1", Channel: "C-requester", CoffeeType: "latte"}
+	if err := d.Dispatch(o); err != nil {
+		t.Fatalf("Dispatch: %s", err)
+	}
+
+	offers := api.offersTo()
+	if len(offers) != 1 {
+		t.Fatalf("expected exactly one initial offer, got %v", offers)
+	}
+	firstBarista := offers[0]
+
+	if err := d.HandleResponse(firstBarista, o.ID, false); err != nil {
+		t.Fatalf("HandleResponse (reject): %s", err)
+	}
+
+	offers = api.offersTo()
+	if len(offers) != 2 {
+		t.Fatalf("expected a re-offer after rejection, got %v", offers)
+	}
+	if offers[1] == firstBarista {
+		t.Fatalf("expected the re-offer to go to a different barista, both were %q", firstBarista)
+	}
+}
+
+func TestDispatchTimeoutThenReoffers(t *testing.T) {
+	baristas := []Barista{
+		{UserID: "B1", Capacity: 1},
+		{UserID: "B2", Capacity: 1},
+	}
+	d, api, _ := newTestDispatcher(t, baristas, "C-fallback", 20*time.Millisecond)
+
+	o := store.Order{ID: "O1", UserID: "U1", Channel: "C-requester", CoffeeType: "latte"}
+	if err := d.Dispatch(o); err != nil {
+		t.Fatalf("Dispatch: %s", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if len(api.offersTo()) >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	offers := api.offersTo()
+	if len(offers) < 2 {
+		t.Fatalf("expected the accept timeout to trigger a re-offer, got %v", offers)
+	}
+	if offers[0] == offers[1] {
+		t.Fatalf("expected the re-offer to exclude the original barista, both were %q", offers[0])
+	}
+}
+
+func TestDispatchEscalatesWhenNoEligibleBarista(t *testing.T) {
+	d, api, _ := newTestDispatcher(t, nil, "C-fallback", time.Minute)
+
+	o := store.Order{ID: "O1", UserID: "U1", Channel: "C-requester", CoffeeType: "latte"}
+	if err := d.Dispatch(o); err != nil {
+		t.Fatalf("Dispatch: %s", err)
+	}
+
+	if len(api.offersTo()) != 0 {
+		t.Fatalf("expected no barista offers when none are eligible, got %v", api.offersTo())
+	}
+	if !api.postedTo("C-fallback") {
+		t.Fatal("expected escalation to post to the fallback channel")
+	}
+}
+
+func TestHandleResponseRejectsDuplicateResponse(t *testing.T) {
+	baristas := []Barista{{UserID: "B1", Capacity: 1}}
+	d, _, st := newTestDispatcher(t, baristas, "C-fallback", time.Minute)
+
+	o := store.Order{ID: "O1", UserID: "U1", Channel: "C-requester", CoffeeType: "latte"}
+	if err := d.Dispatch(o); err != nil {
+		t.Fatalf("Dispatch: %s", err)
+	}
+
+	if err := d.HandleResponse("B1", o.ID, true); err != nil {
+		t.Fatalf("HandleResponse (accept): %s", err)
+	}
+	if st.statusOf(o.ID) != store.StatusBrewing {
+		t.Fatalf("expected order to be brewing after accept, got %q", st.statusOf(o.ID))
+	}
+
+	if err := d.HandleResponse("B1", o.ID, true); err == nil {
+		t.Fatal("expected a duplicate response for an already-handled offer to be rejected")
+	}
+}
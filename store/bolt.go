@@ -0,0 +1,185 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+)
+
+var ordersBucket = []byte("orders")
+
+// BoltStore is the default Store, backed by a local BoltDB file.
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a BoltDB file at path and
+// prepares it for use as an order store.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "open bolt db")
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(ordersBucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "create orders bucket")
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Create implements Store.
+func (s *BoltStore) Create(o Order) (Order, error) {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+
+		if o.ID == "" {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			o.ID = fmt.Sprintf("%d", seq)
+		}
+		if o.SubmittedAt.IsZero() {
+			o.SubmittedAt = time.Now()
+		}
+		if o.Status == "" {
+			o.Status = StatusPending
+		}
+
+		buf, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(o.ID), buf)
+	})
+	if err != nil {
+		return Order{}, errors.Wrap(err, "create order")
+	}
+
+	return o, nil
+}
+
+// UpdateStatus implements Store.
+func (s *BoltStore) UpdateStatus(id string, status Status) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+
+		raw := b.Get([]byte(id))
+		if raw == nil {
+			return errors.Errorf("order %s not found", id)
+		}
+
+		var o Order
+		if err := json.Unmarshal(raw, &o); err != nil {
+			return err
+		}
+		o.Status = status
+
+		buf, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+}
+
+// GetByUser implements Store.
+func (s *BoltStore) GetByUser(userID string, limit int) ([]Order, error) {
+	var orders []Order
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+		return b.ForEach(func(_, v []byte) error {
+			var o Order
+			if err := json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+			if o.UserID == userID {
+				orders = append(orders, o)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get orders by user")
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].SubmittedAt.After(orders[j].SubmittedAt)
+	})
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+
+	return orders, nil
+}
+
+// ListPending implements Store.
+func (s *BoltStore) ListPending() ([]Order, error) {
+	var orders []Order
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+		return b.ForEach(func(_, v []byte) error {
+			var o Order
+			if err := json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+			if o.Status == StatusPending {
+				orders = append(orders, o)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pending orders")
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DeliverAt.Before(orders[j].DeliverAt)
+	})
+
+	return orders, nil
+}
+
+// ListByTimeRange implements Store.
+func (s *BoltStore) ListByTimeRange(from, to time.Time) ([]Order, error) {
+	var orders []Order
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(ordersBucket)
+		return b.ForEach(func(_, v []byte) error {
+			var o Order
+			if err := json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+			if (o.DeliverAt.Equal(from) || o.DeliverAt.After(from)) && o.DeliverAt.Before(to) {
+				orders = append(orders, o)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list orders by time range")
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DeliverAt.Before(orders[j].DeliverAt)
+	})
+
+	return orders, nil
+}
+
+// Close implements Store.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
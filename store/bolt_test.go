@@ -0,0 +1,178 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "orders.db")
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestBoltStoreCreateAssignsIDAndDefaults(t *testing.T) {
+	s := newTestStore(t)
+
+	o, err := s.Create(Order{UserID: "U1", CoffeeType: "latte"})
+	if err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	if o.ID == "" {
+		t.Fatal("expected Create to assign an ID")
+	}
+	if o.Status != StatusPending {
+		t.Fatalf("expected default status %q, got %q", StatusPending, o.Status)
+	}
+	if o.SubmittedAt.IsZero() {
+		t.Fatal("expected Create to set SubmittedAt")
+	}
+}
+
+func TestBoltStoreUpdateStatus(t *testing.T) {
+	s := newTestStore(t)
+
+	o, err := s.Create(Order{UserID: "U1", CoffeeType: "latte"})
+	if err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+
+	if err := s.UpdateStatus(o.ID, StatusBrewing); err != nil {
+		t.Fatalf("UpdateStatus: %s", err)
+	}
+
+	pending, err := s.ListPending()
+	if err != nil {
+		t.Fatalf("ListPending: %s", err)
+	}
+	for _, p := range pending {
+		if p.ID == o.ID {
+			t.Fatalf("order %s still pending after UpdateStatus(StatusBrewing)", o.ID)
+		}
+	}
+}
+
+func TestBoltStoreUpdateStatusUnknownOrder(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.UpdateStatus("does-not-exist", StatusBrewing); err == nil {
+		t.Fatal("expected an error for an unknown order ID")
+	}
+}
+
+func TestBoltStoreGetByUserOrdersNewestFirstAndLimits(t *testing.T) {
+	s := newTestStore(t)
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		o := Order{UserID: "U1", CoffeeType: "latte", SubmittedAt: base.Add(time.Duration(i) * time.Minute)}
+		if _, err := s.Create(o); err != nil {
+			t.Fatalf("Create: %s", err)
+		}
+	}
+	if _, err := s.Create(Order{UserID: "U2", CoffeeType: "latte", SubmittedAt: base}); err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+
+	orders, err := s.GetByUser("U1", 2)
+	if err != nil {
+		t.Fatalf("GetByUser: %s", err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(orders))
+	}
+	if !orders[0].SubmittedAt.After(orders[1].SubmittedAt) {
+		t.Fatalf("expected newest-first ordering, got %v then %v", orders[0].SubmittedAt, orders[1].SubmittedAt)
+	}
+}
+
+func TestBoltStoreListByTimeRange(t *testing.T) {
+	s := newTestStore(t)
+
+	now := time.Now()
+	in := now.Add(30 * time.Minute)
+	out := now.Add(2 * time.Hour)
+	if _, err := s.Create(Order{UserID: "U1", CoffeeType: "latte", DeliverAt: in}); err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+	if _, err := s.Create(Order{UserID: "U1", CoffeeType: "latte", DeliverAt: out}); err != nil {
+		t.Fatalf("Create: %s", err)
+	}
+
+	orders, err := s.ListByTimeRange(now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ListByTimeRange: %s", err)
+	}
+	if len(orders) != 1 || !orders[0].DeliverAt.Equal(in) {
+		t.Fatalf("expected exactly the in-range order, got %+v", orders)
+	}
+}
+
+// TestBoltStoreConcurrentCreateAndUpdate exercises Create and UpdateStatus
+// from many goroutines at once, as the bot does when concurrent Slack
+// interactions hit the same process. BoltDB serializes writes internally; the
+// assertion here is that every order survives the race with a unique ID and
+// its final status, not that the operations happen in any particular order.
+func TestBoltStoreConcurrentCreateAndUpdate(t *testing.T) {
+	s := newTestStore(t)
+
+	const n = 50
+	ids := make([]string, n)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			o, err := s.Create(Order{UserID: "U1", CoffeeType: "latte"})
+			if err != nil {
+				t.Errorf("Create: %s", err)
+				return
+			}
+			mu.Lock()
+			ids[i] = o.ID
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, n)
+	for _, id := range ids {
+		if id == "" {
+			t.Fatal("Create left an empty ID under concurrent access")
+		}
+		if seen[id] {
+			t.Fatalf("duplicate order ID %q assigned under concurrent access", id)
+		}
+		seen[id] = true
+	}
+
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			if err := s.UpdateStatus(ids[i], StatusDelivered); err != nil {
+				t.Errorf("UpdateStatus: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	pending, err := s.ListPending()
+	if err != nil {
+		t.Fatalf("ListPending: %s", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending orders after concurrent updates, got %d", len(pending))
+	}
+}
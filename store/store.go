@@ -0,0 +1,52 @@
+// Package store persists coffee orders across restarts.
+package store
+
+import "time"
+
+// Status represents where an order is in its lifecycle.
+type Status string
+
+// Order statuses.
+const (
+	StatusPending   Status = "pending"
+	StatusAccepted  Status = "accepted"
+	StatusBrewing   Status = "brewing"
+	StatusDelivered Status = "delivered"
+	StatusCancelled Status = "cancelled"
+)
+
+// Order is a single coffee order and its current state.
+type Order struct {
+	ID            string
+	UserID        string
+	Channel       string
+	CoffeeType    string
+	Customization string
+	DeliverAt     time.Time
+	SubmittedAt   time.Time
+	Status        Status
+	MessageTs     string
+}
+
+// Store persists orders and supports the queries the bot needs to answer
+// history and queue requests.
+type Store interface {
+	// Create saves a new order and returns it with its ID populated.
+	Create(o Order) (Order, error)
+
+	// UpdateStatus transitions an existing order to a new status.
+	UpdateStatus(id string, status Status) error
+
+	// GetByUser returns the user's most recent orders, newest first, up to
+	// limit entries.
+	GetByUser(userID string, limit int) ([]Order, error)
+
+	// ListPending returns all orders currently in StatusPending.
+	ListPending() ([]Order, error)
+
+	// ListByTimeRange returns orders with a delivery time in [from, to).
+	ListByTimeRange(from, to time.Time) ([]Order, error)
+
+	// Close releases any resources held by the store.
+	Close() error
+}